@@ -0,0 +1,79 @@
+package esbulk
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+)
+
+// ListIndices returns the names of indices matching pattern (e.g.
+// "logs-*"), via the terse _cat/indices endpoint.
+func ListIndices(options Options, pattern string) ([]string, error) {
+	resp, err := doRequest(options, "GET", "/_cat/indices/"+pattern+"?h=index", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("esbulk: list indices: %s: %s", resp.Status, b)
+	}
+	var names []string
+	for _, line := range strings.Split(strings.TrimSpace(string(b)), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			names = append(names, line)
+		}
+	}
+	return names, nil
+}
+
+// SwapAlias atomically repoints options.Index (the alias name) from
+// whatever concrete index currently backs it to newIndex, via a single
+// /_aliases call, so readers never see a gap. On success, if keep is
+// non-negative, it also prunes backing indices (matched by the
+// "<alias>-*" naming convention, oldest first) beyond the keep newest.
+// A negative keep disables pruning.
+func SwapAlias(options Options, newIndex string, keep int) error {
+	alias := options.Index
+	body := fmt.Sprintf(
+		`{"actions":[{"remove":{"index":"%s-*","alias":"%s"}},{"add":{"index":"%s","alias":"%s"}}]}`,
+		alias, alias, newIndex, alias)
+	resp, err := doRequest(options, "POST", "/_aliases", strings.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		b, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("esbulk: swap alias: %s: %s", resp.Status, b)
+	}
+
+	if keep < 0 {
+		return nil
+	}
+	names, err := ListIndices(options, alias+"-*")
+	if err != nil {
+		return err
+	}
+	// Backing indices are named "<alias>-<timestamp>", so lexical order
+	// is chronological order.
+	sort.Strings(names)
+	if len(names) <= keep {
+		return nil
+	}
+	for _, old := range names[:len(names)-keep] {
+		if old == newIndex {
+			continue
+		}
+		o := options
+		o.Index = old
+		if err := DeleteIndex(o); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package esbulk
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// aliasTestServer fakes just enough of _aliases, _cat/indices and index
+// deletion for SwapAlias's pruning math: backing is the set of indices
+// that currently exist, mutated as the server handles swap/delete
+// requests.
+func aliasTestServer(t *testing.T, backing map[string]bool) *httptest.Server {
+	t.Helper()
+	var mu sync.Mutex
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/_aliases":
+			w.WriteHeader(http.StatusOK)
+		case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/_cat/indices/"):
+			var names []string
+			for name := range backing {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			io.WriteString(w, strings.Join(names, "\n")+"\n")
+		case r.Method == http.MethodDelete:
+			delete(backing, strings.TrimPrefix(r.URL.Path, "/"))
+			w.WriteHeader(http.StatusOK)
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+}
+
+func TestSwapAliasPruning(t *testing.T) {
+	backing := map[string]bool{
+		"logs-20200101-000000": true,
+		"logs-20200102-000000": true,
+		"logs-20200103-000000": true,
+		"logs-20200104-000000": true,
+	}
+	srv := aliasTestServer(t, backing)
+	defer srv.Close()
+
+	options := optionsForServer(t, srv)
+	options.Index = "logs"
+
+	if err := SwapAlias(options, "logs-20200104-000000", 2); err != nil {
+		t.Fatalf("SwapAlias() error = %v", err)
+	}
+
+	want := map[string]bool{
+		"logs-20200103-000000": true,
+		"logs-20200104-000000": true,
+	}
+	if len(backing) != len(want) {
+		t.Fatalf("backing indices = %v, want %v", backing, want)
+	}
+	for name := range want {
+		if !backing[name] {
+			t.Fatalf("backing indices = %v, want %v", backing, want)
+		}
+	}
+}
+
+func TestSwapAliasNegativeKeepDisablesPruning(t *testing.T) {
+	backing := map[string]bool{
+		"logs-20200101-000000": true,
+		"logs-20200102-000000": true,
+	}
+	srv := aliasTestServer(t, backing)
+	defer srv.Close()
+
+	options := optionsForServer(t, srv)
+	options.Index = "logs"
+
+	if err := SwapAlias(options, "logs-20200102-000000", -1); err != nil {
+		t.Fatalf("SwapAlias() error = %v", err)
+	}
+	if len(backing) != 2 {
+		t.Fatalf("backing indices = %v, want both kept (keep<0 disables pruning)", backing)
+	}
+}
+
+func TestSwapAliasKeepsNewIndexEvenIfOlderByName(t *testing.T) {
+	// newIndex itself is always kept, even if pruning's keep-newest
+	// count would otherwise have dropped it (e.g. a clock skew made its
+	// timestamp sort earlier than an index that is about to be pruned).
+	backing := map[string]bool{
+		"logs-20200101-000000": true,
+		"logs-20200105-000000": true,
+	}
+	srv := aliasTestServer(t, backing)
+	defer srv.Close()
+
+	options := optionsForServer(t, srv)
+	options.Index = "logs"
+
+	if err := SwapAlias(options, "logs-20200101-000000", 0); err != nil {
+		t.Fatalf("SwapAlias() error = %v", err)
+	}
+	if !backing["logs-20200101-000000"] {
+		t.Fatalf("backing indices = %v, want newIndex kept regardless of sort order", backing)
+	}
+}
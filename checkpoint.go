@@ -0,0 +1,226 @@
+package esbulk
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+)
+
+// State is the JSON document persisted to the -state file: enough to
+// confirm the input hasn't changed since the last run, plus the point
+// up to which every batch has been fully acknowledged.
+type State struct {
+	Input      string    `json:"input"`
+	InputSize  int64     `json:"input_size"`
+	InputMTime time.Time `json:"input_mtime"`
+
+	// Offset is the byte offset, in the (possibly decompressed) input
+	// stream, immediately after the last line whose enclosing batch was
+	// acked. Only meaningful for seekable (non-gzip) input.
+	Offset int64 `json:"offset"`
+	// LineCount is the number of raw input lines (including blanks)
+	// read up to the same point, used to resume non-seekable (gzip)
+	// input by scanning forward and discarding already-indexed lines.
+	LineCount  int64 `json:"line_count"`
+	BatchCount int64 `json:"batch_count"`
+	DocCount   int64 `json:"doc_count"`
+}
+
+// Matches reports whether s was written for the same input file: same
+// path, size and modification time. A changed file invalidates any
+// saved offset.
+func (s State) Matches(path string, size int64, mtime time.Time) bool {
+	return s.Input == path && s.InputSize == size && s.InputMTime.Equal(mtime)
+}
+
+// LoadState reads a previously persisted checkpoint from path.
+func LoadState(path string) (State, error) {
+	var s State
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return s, err
+	}
+	err = json.Unmarshal(b, &s)
+	return s, err
+}
+
+// Checkpoint tracks in-flight bulk batches and periodically persists a
+// State once batches have been acked in order, so a killed run can
+// resume from the last point every worker actually finished, rather
+// than from whatever line happened to be read last.
+//
+// Batches may be acked out of order (workers run concurrently), so
+// Checkpoint only advances the persisted offset through a contiguous
+// run of acked batch IDs starting at the last watermark - a single
+// stalled batch holds the watermark back without losing track of the
+// ones that finished after it.
+type Checkpoint struct {
+	path  string
+	every int64
+
+	mu        sync.Mutex
+	meta      State
+	nextID    int64
+	offsets   map[int64]int64
+	lines     map[int64]int64
+	cursors   map[int64]interface{}
+	docs      map[int64]int
+	completed map[int64]bool
+	watermark int64
+	sinceSave int64
+
+	// onAdvance, if set, is called with the Cursor Position recorded at
+	// Begin/BeginWithCursor for the highest batch ID the watermark has
+	// just advanced through, letting a streaming Source (Kafka, AMQP)
+	// commit its own progress in step with the same contiguous-ack
+	// ordering that protects the -state file.
+	onAdvance func(cursor interface{})
+}
+
+// NewCheckpoint returns a Checkpoint that persists to path every `every`
+// newly-acked batches, seeded with meta (typically loaded via LoadState
+// on resume, or a fresh State describing the input file otherwise).
+func NewCheckpoint(path string, every int, meta State) *Checkpoint {
+	if every <= 0 {
+		every = 1
+	}
+	return &Checkpoint{
+		path:      path,
+		every:     int64(every),
+		meta:      meta,
+		offsets:   make(map[int64]int64),
+		lines:     make(map[int64]int64),
+		cursors:   make(map[int64]interface{}),
+		docs:      make(map[int64]int),
+		completed: make(map[int64]bool),
+		watermark: -1,
+	}
+}
+
+// SetOnAdvance registers fn to be called after each Ack that advances
+// the watermark, with the Cursor Position of the furthest batch just
+// advanced through. main wires this to a streaming Source's Ack so
+// Kafka/AMQP commit in lockstep with indexing progress.
+func (c *Checkpoint) SetOnAdvance(fn func(cursor interface{})) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onAdvance = fn
+}
+
+// Begin registers a new in-flight batch of n docs ending at the given
+// byte offset and raw line count, and returns the monotonically
+// increasing ID it must later be Acked with. Callers must call Begin in
+// input order.
+func (c *Checkpoint) Begin(offset, lineCount int64, n int) int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	id := c.begin(n)
+	c.offsets[id] = offset
+	c.lines[id] = lineCount
+	return id
+}
+
+// BeginWithCursor is Begin for a streaming Source tracked by Cursor
+// rather than by byte offset: cursor is whatever that Source's
+// Position returned for the most recently read line in this batch.
+func (c *Checkpoint) BeginWithCursor(cursor interface{}, n int) int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	id := c.begin(n)
+	c.cursors[id] = cursor
+	return id
+}
+
+// begin allocates the next monotonic batch ID and its doc count.
+// Callers hold c.mu.
+func (c *Checkpoint) begin(n int) int64 {
+	id := c.nextID
+	c.nextID++
+	c.docs[id] = n
+	return id
+}
+
+// Ack marks batch id as fully indexed. Once the watermark can advance
+// through a contiguous run of acked IDs, it does so, updating the
+// in-memory State and, every `every` such advances, persisting it.
+func (c *Checkpoint) Ack(id int64) error {
+	c.mu.Lock()
+	if _, ok := c.docs[id]; !ok {
+		c.mu.Unlock()
+		return fmt.Errorf("esbulk: checkpoint: unknown batch %d", id)
+	}
+	c.completed[id] = true
+	advanced := false
+	var lastCursor interface{}
+	haveCursor := false
+	for c.completed[c.watermark+1] {
+		c.watermark++
+		c.meta.Offset = c.offsets[c.watermark]
+		c.meta.LineCount = c.lines[c.watermark]
+		c.meta.BatchCount++
+		c.meta.DocCount += int64(c.docs[c.watermark])
+		if cur, ok := c.cursors[c.watermark]; ok {
+			lastCursor, haveCursor = cur, true
+			delete(c.cursors, c.watermark)
+		}
+		delete(c.offsets, c.watermark)
+		delete(c.lines, c.watermark)
+		delete(c.docs, c.watermark)
+		delete(c.completed, c.watermark)
+		c.sinceSave++
+		advanced = true
+	}
+	onAdvance := c.onAdvance
+	var saveErr error
+	if advanced && c.sinceSave >= c.every {
+		c.sinceSave = 0
+		saveErr = c.save()
+	}
+	c.mu.Unlock()
+
+	// Called outside the lock: onAdvance commits to a remote broker
+	// (Kafka/AMQP), which can block on a slow or unreachable network -
+	// holding c.mu across that call would freeze every other worker's
+	// Ack, file-sourced or not, on a single broker hiccup.
+	if advanced && haveCursor && onAdvance != nil {
+		onAdvance(lastCursor)
+	}
+	return saveErr
+}
+
+// save writes the current State to path, via a temp file and rename so
+// a crash mid-write never leaves a corrupt state file behind. A
+// Checkpoint used only for its ack-watermark (no -state file, e.g. a
+// streaming Source tracked purely via Cursor) has an empty path and
+// skips persistence entirely.
+func (c *Checkpoint) save() error {
+	if c.path == "" {
+		return nil
+	}
+	b, err := json.MarshalIndent(c.meta, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := c.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, c.path)
+}
+
+// Remove deletes the state file. Callers should call this on a clean
+// shutdown, so the next run starts fresh rather than needlessly
+// resuming. A no-op when Checkpoint has no path (streaming-only use).
+func (c *Checkpoint) Remove() error {
+	if c.path == "" {
+		return nil
+	}
+	err := os.Remove(c.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,103 @@
+package esbulk
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestCheckpointBeginAckAdvancesWatermark guards the happy-path
+// contiguous-ack bookkeeping: the watermark, and the State it produces,
+// must only advance through a run of acked IDs starting right after the
+// last watermark, holding back on a gap even if later batches finish
+// first, and must catch up once the gap is filled.
+func TestCheckpointBeginAckAdvancesWatermark(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	c := NewCheckpoint(path, 1, State{Input: "in.ndjson"})
+
+	id0 := c.Begin(10, 1, 5)
+	id1 := c.Begin(20, 2, 5)
+	id2 := c.Begin(30, 3, 5)
+
+	if err := c.Ack(id1); err != nil {
+		t.Fatalf("Ack(id1) error = %v", err)
+	}
+	if c.meta.Offset != 0 || c.meta.BatchCount != 0 {
+		t.Fatalf("watermark advanced past a gap: meta = %+v", c.meta)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("state file written before the watermark advanced")
+	}
+
+	if err := c.Ack(id0); err != nil {
+		t.Fatalf("Ack(id0) error = %v", err)
+	}
+	if c.meta.Offset != 20 || c.meta.LineCount != 2 || c.meta.BatchCount != 2 || c.meta.DocCount != 10 {
+		t.Fatalf("watermark did not catch up through id0 and id1: meta = %+v", c.meta)
+	}
+	persisted, err := LoadState(path)
+	if err != nil {
+		t.Fatalf("LoadState() error = %v", err)
+	}
+	if persisted.Offset != 20 || persisted.BatchCount != 2 {
+		t.Fatalf("persisted state = %+v, want Offset=20 BatchCount=2", persisted)
+	}
+
+	if err := c.Ack(id2); err != nil {
+		t.Fatalf("Ack(id2) error = %v", err)
+	}
+	if c.meta.Offset != 30 || c.meta.BatchCount != 3 || c.meta.DocCount != 15 {
+		t.Fatalf("watermark did not advance through id2: meta = %+v", c.meta)
+	}
+}
+
+// TestCheckpointAckUnknownBatch guards against acking a batch ID that
+// was never returned by Begin/BeginWithCursor.
+func TestCheckpointAckUnknownBatch(t *testing.T) {
+	c := NewCheckpoint("", 1, State{})
+	if err := c.Ack(42); err == nil {
+		t.Fatal("Ack() of an unknown batch ID should return an error")
+	}
+}
+
+// TestCheckpointAckDoesNotHoldLockDuringOnAdvance guards against a
+// slow or blocked onAdvance (a Kafka commit, an AMQP ack - real
+// network calls) freezing every other batch's Ack, which would happen
+// if onAdvance ran with c.mu still held.
+func TestCheckpointAckDoesNotHoldLockDuringOnAdvance(t *testing.T) {
+	c := NewCheckpoint("", 1, State{})
+
+	blocking := make(chan struct{})
+	release := make(chan struct{})
+	c.SetOnAdvance(func(cursor interface{}) {
+		close(blocking)
+		<-release
+	})
+
+	id := c.BeginWithCursor("cursor-0", 1)
+
+	ackDone := make(chan error, 1)
+	go func() { ackDone <- c.Ack(id) }()
+
+	select {
+	case <-blocking:
+	case <-time.After(2 * time.Second):
+		t.Fatal("onAdvance was never called")
+	}
+	defer close(release)
+
+	// Begin only needs c.mu; if Ack still held it while blocked inside
+	// onAdvance, this would hang until release is closed.
+	unblocked := make(chan struct{})
+	go func() {
+		c.Begin(0, 0, 1)
+		close(unblocked)
+	}()
+
+	select {
+	case <-unblocked:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Checkpoint.Begin blocked: Ack is still holding the lock during onAdvance")
+	}
+}
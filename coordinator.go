@@ -0,0 +1,90 @@
+package esbulk
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// defaultCoordinatorPause is how long the gate stays closed after
+// threshold consecutive failures, absent an intervening success.
+const defaultCoordinatorPause = 5 * time.Second
+
+// Coordinator shares backoff state across every worker in a single run.
+// A bulk failure anywhere bumps a shared counter; once consecutive
+// failures reach threshold, all workers block in Wait until a
+// subsequent success reopens the gate, or pause elapses and it reopens
+// on its own - a worker can't be the one to reopen a gate it is itself
+// blocked behind, so the gate must also be able to clear without
+// anyone getting past Wait.
+type Coordinator struct {
+	threshold int64
+	pause     time.Duration
+	failures  atomic.Int64
+
+	mu   sync.Mutex
+	gate chan struct{}
+}
+
+// NewCoordinator returns a Coordinator that pauses all workers once
+// threshold consecutive bulk attempts have failed, across the whole
+// pool.
+func NewCoordinator(threshold int64) *Coordinator {
+	if threshold <= 0 {
+		threshold = 1
+	}
+	return &Coordinator{threshold: threshold, pause: defaultCoordinatorPause}
+}
+
+// ReportFailure records a failed bulk attempt. Once threshold
+// consecutive failures have accumulated, the gate closes for business
+// and a timer is armed to reopen it after pause, even if no worker
+// ever reports success in the meantime.
+func (c *Coordinator) ReportFailure() {
+	if c.failures.Add(1) < c.threshold {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.gate != nil {
+		return
+	}
+	gate := make(chan struct{})
+	c.gate = gate
+	time.AfterFunc(c.pause, func() { c.reopen(gate) })
+}
+
+// reopen closes gate and clears it, but only if it is still the
+// current gate - a concurrent ReportSuccess may have already done so,
+// and closing twice would panic.
+func (c *Coordinator) reopen(gate chan struct{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.gate == gate {
+		close(c.gate)
+		c.gate = nil
+	}
+}
+
+// ReportSuccess resets the failure count and releases any worker
+// currently blocked in Wait.
+func (c *Coordinator) ReportSuccess() {
+	c.failures.Store(0)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.gate != nil {
+		close(c.gate)
+		c.gate = nil
+	}
+}
+
+// Wait blocks while the gate is closed for business, i.e. while enough
+// consecutive failures have piled up that every worker should pause.
+func (c *Coordinator) Wait() {
+	c.mu.Lock()
+	gate := c.gate
+	c.mu.Unlock()
+	if gate != nil {
+		<-gate
+	}
+}
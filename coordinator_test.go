@@ -0,0 +1,28 @@
+package esbulk
+
+import (
+	"testing"
+	"time"
+)
+
+// TestCoordinatorWaitReopensWithoutSuccess guards against the gate
+// deadlocking forever: once no worker can get past Wait, nothing can
+// ever call ReportSuccess to reopen it, so the gate itself must time
+// out on its own.
+func TestCoordinatorWaitReopensWithoutSuccess(t *testing.T) {
+	c := NewCoordinator(1)
+	c.pause = 50 * time.Millisecond
+	c.ReportFailure()
+
+	done := make(chan struct{})
+	go func() {
+		c.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Wait never returned: gate deadlocked")
+	}
+}
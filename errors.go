@@ -0,0 +1,55 @@
+package esbulk
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+)
+
+// failedDoc is one line of the NDJSON errors file: the original document
+// plus the reason Elasticsearch permanently rejected it. A document that
+// is not valid JSON is recorded as a JSON string holding the raw line.
+type failedDoc struct {
+	Doc    json.RawMessage `json:"doc"`
+	Status int             `json:"status"`
+	Error  string          `json:"error,omitempty"`
+}
+
+// ErrorSink serializes NDJSON error records to a file shared by all
+// workers, so a failing document no longer kills the whole run.
+type ErrorSink struct {
+	mu sync.Mutex
+	w  *os.File
+}
+
+// NewErrorSink creates (or truncates) path for NDJSON error records.
+func NewErrorSink(path string) (*ErrorSink, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &ErrorSink{w: f}, nil
+}
+
+// Write appends one failure record as a single NDJSON line.
+func (s *ErrorSink) Write(doc failedDoc) error {
+	if !json.Valid(doc.Doc) {
+		doc.Doc, _ = json.Marshal(string(doc.Doc))
+	}
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.w.Write(b)
+	return err
+}
+
+// Close flushes and closes the underlying file.
+func (s *ErrorSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.w.Close()
+}
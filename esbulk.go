@@ -0,0 +1,115 @@
+// Package esbulk provides fast, parallel bulk indexing into
+// Elasticsearch (and compatible) clusters.
+package esbulk
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// Options bundle the parameters that control a single indexing run, from
+// server location and batching to retry behavior. Callers fill in
+// Host/Port/Index (or call SetServer) plus whichever knobs apply before
+// handing Options to Worker.
+type Options struct {
+	Host      string
+	Port      int
+	Index     string
+	DocType   string
+	BatchSize int
+	Verbose   bool
+	Scheme    string
+	IDField   string
+	Username  string
+	Password  string
+
+	// MaxRetries caps how many times a failed batch is retried before
+	// Worker gives up on it. Zero means use a sane default.
+	MaxRetries int
+	// BackoffInitial and BackoffMax bound the exponential backoff
+	// applied between retries of a failed batch. Zero means use a sane
+	// default.
+	BackoffInitial time.Duration
+	BackoffMax     time.Duration
+
+	// Coordinator, if set, synchronizes backoff across every worker
+	// sharing this Options value, so the whole pool pauses together
+	// when the cluster is overloaded rather than each worker retrying
+	// independently.
+	Coordinator *Coordinator
+	// Errors, if set, receives one NDJSON record per permanently
+	// rejected document instead of aborting the run.
+	Errors *ErrorSink
+
+	// Checkpoint, if set, is notified as batches complete so indexing
+	// progress can be persisted and a killed run resumed.
+	Checkpoint *Checkpoint
+
+	// IndexField, if set, names a field in each document whose value
+	// becomes that document's index, overriding Index. IndexTemplate,
+	// if also set, takes precedence.
+	IndexField string
+	// IndexTemplate, if set, is a Go template (e.g.
+	// "logs-{{.service}}-{{.date}}") evaluated against each decoded
+	// document to determine its index.
+	IndexTemplate string
+	// ZeroReplica mirrors the -0 flag: indices discovered via routing
+	// get their replica count zeroed for the run, same as the
+	// statically-configured Index.
+	ZeroReplica bool
+	// Mapping, if set, is applied to every index discovered via
+	// routing, same as the statically-configured Index.
+	Mapping string
+	// Touched, if set, records every index name actually written to
+	// during the run and is how routing gets tuned (refresh interval,
+	// replicas, mapping) and created on first sight. Must be the same
+	// *sync.Map shared across every Worker sharing these Options.
+	Touched *sync.Map
+
+	indexTpl *template.Template
+}
+
+// Compile parses IndexTemplate once, if set, so per-document routing
+// doesn't reparse it. Callers that set IndexTemplate must call Compile
+// before passing Options to Worker.
+func (o *Options) Compile() error {
+	if o.IndexTemplate == "" {
+		return nil
+	}
+	tpl, err := template.New("index").Option("missingkey=error").Parse(o.IndexTemplate)
+	if err != nil {
+		return fmt.Errorf("esbulk: invalid index template: %w", err)
+	}
+	o.indexTpl = tpl
+	return nil
+}
+
+// SetServer parses a server URL, such as "https://example.org:9201", and
+// fills in Scheme, Host and Port accordingly.
+func (o *Options) SetServer(server string) error {
+	u, err := url.Parse(server)
+	if err != nil {
+		return err
+	}
+	if u.Scheme == "" || u.Hostname() == "" {
+		return fmt.Errorf("esbulk: invalid server: %s", server)
+	}
+	o.Scheme = u.Scheme
+	o.Host = u.Hostname()
+	if p := u.Port(); p != "" {
+		port, err := strconv.Atoi(p)
+		if err != nil {
+			return err
+		}
+		o.Port = port
+	} else if u.Scheme == "https" {
+		o.Port = 443
+	} else {
+		o.Port = 80
+	}
+	return nil
+}
@@ -0,0 +1,240 @@
+package esbulk
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// action is the per-document bulk action line, e.g.
+// {"index":{"_index":"...","_type":"...","_id":"..."}}
+type action struct {
+	Index *actionMeta `json:"index"`
+}
+
+type actionMeta struct {
+	Index string `json:"_index"`
+	Type  string `json:"_type,omitempty"`
+	ID    string `json:"_id,omitempty"`
+}
+
+// docID extracts options.IDField from a JSON document line, if
+// configured. It returns an empty string, meaning Elasticsearch should
+// autogenerate an id, when the field is not configured or not present.
+func docID(options Options, line string) string {
+	if options.IDField == "" {
+		return ""
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal([]byte(line), &doc); err != nil {
+		return ""
+	}
+	v, ok := doc[options.IDField]
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+// resolveIndex determines which index a document line should be routed
+// to: IndexTemplate, evaluated against the decoded document, takes
+// precedence over IndexField (a literal field lookup), which takes
+// precedence over the statically-configured Index. Like docID, it falls
+// back to Index rather than erroring on an unparsable or non-matching
+// line, since one malformed routing key shouldn't sink the whole batch.
+func resolveIndex(options Options, line string) string {
+	if options.indexTpl == nil && options.IndexField == "" {
+		return options.Index
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal([]byte(line), &doc); err != nil {
+		return options.Index
+	}
+	if options.indexTpl != nil {
+		var buf bytes.Buffer
+		if err := options.indexTpl.Execute(&buf, doc); err != nil {
+			return options.Index
+		}
+		return buf.String()
+	}
+	if v, ok := doc[options.IndexField]; ok {
+		return fmt.Sprintf("%v", v)
+	}
+	return options.Index
+}
+
+// indexSetup is what Options.Touched stores per index: done is closed
+// once that index's CreateIndex/ApplyIndexSettings/PutMapping sequence
+// finishes, successfully or not, and err holds the result. A bare
+// sentinel value would only deduplicate who starts the setup, not make
+// everyone else wait for it to finish - with two workers routing their
+// first batch to the same new index at once, the second would otherwise
+// see it as "already ensured" and start indexing into it before the
+// first worker's CreateIndex/mapping/settings calls had completed.
+type indexSetup struct {
+	done chan struct{}
+	err  error
+}
+
+// ensureIndex creates idx the first time a routed document lands there,
+// applying the same bulk-load tuning (refresh interval off, optionally
+// zero replicas) and mapping main() applies to a statically-configured
+// Index up front. Concurrent callers for the same new idx block until
+// the first caller's setup finishes, and all see its result. It is a
+// no-op once idx has already been ensured, and a no-op entirely when
+// Touched is nil (routing disabled).
+func ensureIndex(options Options, idx string) error {
+	if options.Touched == nil {
+		return nil
+	}
+	setup := &indexSetup{done: make(chan struct{})}
+	v, loaded := options.Touched.LoadOrStore(idx, setup)
+	setup = v.(*indexSetup)
+	if loaded {
+		<-setup.done
+		return setup.err
+	}
+	defer close(setup.done)
+
+	o := options
+	o.Index = idx
+	if err := CreateIndex(o); err != nil {
+		setup.err = err
+		return err
+	}
+	if err := ApplyIndexSettings(o, idx, `{"index": {"refresh_interval": "-1"}}`); err != nil {
+		setup.err = err
+		return err
+	}
+	if o.ZeroReplica {
+		if err := ApplyIndexSettings(o, idx, `{"index": {"number_of_replicas": 0}}`); err != nil {
+			setup.err = err
+			return err
+		}
+	}
+	if o.Mapping != "" {
+		if err := PutMapping(o, strings.NewReader(o.Mapping)); err != nil {
+			setup.err = err
+			return err
+		}
+	}
+	return nil
+}
+
+// buildBulkBody renders the NDJSON body for a _bulk request: one action
+// line followed by the document itself, for every doc in docs. Routed
+// indices are created (and tuned) on first sight.
+func buildBulkBody(options Options, docs []string) ([]byte, error) {
+	var buf bytes.Buffer
+	for _, doc := range docs {
+		idx := resolveIndex(options, doc)
+		if err := ensureIndex(options, idx); err != nil {
+			return nil, err
+		}
+		a := action{Index: &actionMeta{
+			Index: idx,
+			Type:  options.DocType,
+			ID:    docID(options, doc),
+		}}
+		line, _ := json.Marshal(a)
+		buf.Write(line)
+		buf.WriteByte('\n')
+		buf.WriteString(doc)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
+
+// doRequest issues an HTTP request against the configured server,
+// applying basic auth if configured.
+func doRequest(options Options, method, path string, body io.Reader) (*http.Response, error) {
+	link := fmt.Sprintf("%s://%s:%d%s", options.Scheme, options.Host, options.Port, path)
+	req, err := http.NewRequest(method, link, body)
+	if err != nil {
+		return nil, err
+	}
+	if options.Username != "" && options.Password != "" {
+		req.SetBasicAuth(options.Username, options.Password)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	client := &http.Client{}
+	return client.Do(req)
+}
+
+// postBulk POSTs docs to the _bulk endpoint and returns the raw
+// response, for the caller to classify.
+func postBulk(options Options, docs []string) (*http.Response, error) {
+	body, err := buildBulkBody(options, docs)
+	if err != nil {
+		return nil, err
+	}
+	return doRequest(options, "POST", "/_bulk", bytes.NewReader(body))
+}
+
+// ApplyIndexSettings PUTs a settings body, e.g.
+// {"index":{"refresh_interval":"-1"}}, to a specific index. Exported so
+// callers can reuse it beyond the statically-configured Index, e.g. to
+// tune or restore settings across every index touched by routing.
+func ApplyIndexSettings(options Options, index, body string) error {
+	resp, err := doRequest(options, "PUT", "/"+index+"/_settings", strings.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if options.Verbose {
+		log.Printf("applied setting to %s: %s with status %s\n", index, body, resp.Status)
+	}
+	if resp.StatusCode >= 400 {
+		b, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("esbulk: settings %s on %s: %s: %s", body, index, resp.Status, b)
+	}
+	return nil
+}
+
+// CreateIndex creates options.Index, if it does not exist yet.
+func CreateIndex(options Options) error {
+	resp, err := doRequest(options, "PUT", "/"+options.Index, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	b, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode >= 400 && !strings.Contains(string(b), "resource_already_exists_exception") {
+		return fmt.Errorf("esbulk: create index: %s: %s", resp.Status, b)
+	}
+	return nil
+}
+
+// DeleteIndex removes options.Index. A 404 (index does not exist) is
+// not treated as an error.
+func DeleteIndex(options Options) error {
+	resp, err := doRequest(options, "DELETE", "/"+options.Index, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 && resp.StatusCode != 404 {
+		b, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("esbulk: delete index: %s: %s", resp.Status, b)
+	}
+	return nil
+}
+
+// PutMapping applies a mapping document, read from r, to options.Index.
+func PutMapping(options Options, r io.Reader) error {
+	resp, err := doRequest(options, "PUT", fmt.Sprintf("/%s/_mapping/%s", options.Index, options.DocType), r)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		b, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("esbulk: put mapping: %s: %s", resp.Status, b)
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package esbulk
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func optionsForServer(t *testing.T, srv *httptest.Server) Options {
+	t.Helper()
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	port, err := strconv.Atoi(u.Port())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Options{Scheme: u.Scheme, Host: u.Hostname(), Port: port, DocType: "default"}
+}
+
+func TestResolveIndex(t *testing.T) {
+	cases := []struct {
+		name    string
+		options Options
+		line    string
+		want    string
+	}{
+		{
+			name:    "no routing configured falls back to Index",
+			options: Options{Index: "static"},
+			line:    `{"date":"2020-01-01"}`,
+			want:    "static",
+		},
+		{
+			name:    "IndexField looks up a literal field",
+			options: Options{Index: "static", IndexField: "date"},
+			line:    `{"date":"2020-01-01"}`,
+			want:    "2020-01-01",
+		},
+		{
+			name:    "IndexField falls back to Index when field is absent",
+			options: Options{Index: "static", IndexField: "date"},
+			line:    `{"other":1}`,
+			want:    "static",
+		},
+		{
+			name:    "IndexTemplate takes precedence over IndexField",
+			options: Options{Index: "static", IndexField: "date", IndexTemplate: "logs-{{.service}}"},
+			line:    `{"date":"2020-01-01","service":"api"}`,
+			want:    "logs-api",
+		},
+		{
+			name:    "malformed line falls back to Index",
+			options: Options{Index: "static", IndexField: "date"},
+			line:    `not json`,
+			want:    "static",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			o := c.options
+			if err := o.Compile(); err != nil {
+				t.Fatal(err)
+			}
+			if got := resolveIndex(o, c.line); got != c.want {
+				t.Fatalf("resolveIndex() = %q, want %q", got, c.want)
+			}
+		})
+	}
+}
+
+func TestEnsureIndexCreatesOnce(t *testing.T) {
+	var creates atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut && r.URL.Path == "/logs-api" {
+			creates.Add(1)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	options := optionsForServer(t, srv)
+	options.Touched = &sync.Map{}
+
+	for i := 0; i < 3; i++ {
+		if err := ensureIndex(options, "logs-api"); err != nil {
+			t.Fatalf("ensureIndex() error = %v", err)
+		}
+	}
+	if got := creates.Load(); got != 1 {
+		t.Fatalf("index was created %d times, want 1", got)
+	}
+}
+
+func TestEnsureIndexConcurrentCallersWaitForSetup(t *testing.T) {
+	release := make(chan struct{})
+	var creates atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut && r.URL.Path == "/logs-api" {
+			creates.Add(1)
+			<-release
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	options := optionsForServer(t, srv)
+	options.Touched = &sync.Map{}
+
+	first := make(chan error, 1)
+	go func() { first <- ensureIndex(options, "logs-api") }()
+
+	// Give the first caller time to reach CreateIndex and block on
+	// release, so the second caller observes an in-progress setup.
+	time.Sleep(50 * time.Millisecond)
+
+	second := make(chan error, 1)
+	go func() { second <- ensureIndex(options, "logs-api") }()
+
+	select {
+	case err := <-second:
+		t.Fatalf("second ensureIndex returned before setup finished (err=%v): it should have blocked", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(release)
+
+	if err := <-first; err != nil {
+		t.Fatalf("first ensureIndex() error = %v", err)
+	}
+	if err := <-second; err != nil {
+		t.Fatalf("second ensureIndex() error = %v", err)
+	}
+	if got := creates.Load(); got != 1 {
+		t.Fatalf("index was created %d times, want 1", got)
+	}
+}
+
+func TestEnsureIndexNoopWithoutTouched(t *testing.T) {
+	if err := ensureIndex(Options{}, "whatever"); err != nil {
+		t.Fatalf("ensureIndex() error = %v, want nil when Touched is nil", err)
+	}
+}
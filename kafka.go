@@ -0,0 +1,94 @@
+package esbulk
+
+import (
+	"context"
+	"fmt"
+
+	kafka "github.com/segmentio/kafka-go"
+)
+
+// KafkaSource reads documents from one or more Kafka topics as a
+// consumer group member, committing the group's offsets only up to
+// whatever Checkpoint's ack-watermark reports as fully indexed - never
+// ahead of it - so a crash never loses a message that was read but not
+// yet durably bulk-acked.
+type KafkaSource struct {
+	reader *kafka.Reader
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// pending holds the latest message read on each partition, keyed
+	// by partition, so Position can snapshot what Ack later commits.
+	// Entries are replaced by newer messages, never removed.
+	pending map[int]kafka.Message
+}
+
+// kafkaCursor is the opaque value KafkaSource hands to Checkpoint via
+// Cursor: the latest message consumed per partition, since Kafka
+// commits are per-partition offsets.
+type kafkaCursor map[int]kafka.Message
+
+// NewKafkaSource connects to broker as a member of group, consuming
+// topic. Offsets are committed explicitly via Ack, never
+// automatically, so indexing progress and commit progress stay in
+// lockstep.
+func NewKafkaSource(broker, topic, group string) (*KafkaSource, error) {
+	if group == "" {
+		return nil, fmt.Errorf("esbulk: kafka source requires a consumer group")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	reader := kafka.NewReader(kafka.ReaderConfig{
+		Brokers:     []string{broker},
+		Topic:       topic,
+		GroupID:     group,
+		StartOffset: kafka.FirstOffset,
+	})
+	return &KafkaSource{
+		reader:  reader,
+		ctx:     ctx,
+		cancel:  cancel,
+		pending: make(map[int]kafka.Message),
+	}, nil
+}
+
+// Next blocks until the next message arrives, or the source is
+// Closed, in which case it returns the context's cancellation error.
+func (s *KafkaSource) Next() (string, error) {
+	msg, err := s.reader.FetchMessage(s.ctx)
+	if err != nil {
+		return "", err
+	}
+	s.pending[msg.Partition] = msg
+	return string(msg.Value), nil
+}
+
+// Position implements Cursor: a snapshot of the latest message seen on
+// each partition so far.
+func (s *KafkaSource) Position() interface{} {
+	snapshot := make(kafkaCursor, len(s.pending))
+	for p, m := range s.pending {
+		snapshot[p] = m
+	}
+	return snapshot
+}
+
+// Ack implements Cursor, committing the given per-partition offsets to
+// the consumer group.
+func (s *KafkaSource) Ack(pos interface{}) error {
+	cursor, ok := pos.(kafkaCursor)
+	if !ok {
+		return fmt.Errorf("esbulk: kafka source: unexpected cursor type %T", pos)
+	}
+	msgs := make([]kafka.Message, 0, len(cursor))
+	for _, m := range cursor {
+		msgs = append(msgs, m)
+	}
+	return s.reader.CommitMessages(s.ctx, msgs...)
+}
+
+// Close stops fetching and closes the underlying consumer group
+// session.
+func (s *KafkaSource) Close() error {
+	s.cancel()
+	return s.reader.Close()
+}
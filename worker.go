@@ -0,0 +1,199 @@
+package esbulk
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	defaultMaxRetries     = 10
+	defaultBackoffInitial = 100 * time.Millisecond
+	defaultBackoffMax     = 30 * time.Second
+)
+
+// Batch is a group of documents dispatched to a Worker as a unit, along
+// with the bookkeeping a Checkpoint needs to know when it is safe to
+// resume. ID is assigned in input order by the batch producer (main),
+// so Checkpoint.Ack can detect which batches completed out of order.
+type Batch struct {
+	ID     int64
+	Docs   []string
+	Offset int64 // byte offset in the input immediately after the last doc
+	Line   int64 // raw input line number immediately after the last doc
+}
+
+// Worker consumes batches from queue and indexes each into
+// Elasticsearch, retrying transient per-item and whole-request failures
+// with exponential backoff. It returns once queue is closed, or once a
+// batch fails permanently (onError is called and the worker stops
+// taking new batches, leaving its peers to drain the rest of queue).
+// It signals wg either way.
+//
+// onError is called instead of the worker dying outright so the caller
+// can fold a fatal indexing failure into the same stop-and-drain path
+// used for a signal, instead of the process exiting mid-run and
+// skipping restoreSettings/src.Close.
+func Worker(name string, options Options, queue chan Batch, wg *sync.WaitGroup, onError func(error)) {
+	defer wg.Done()
+	for batch := range queue {
+		if err := indexBatch(name, options, batch.Docs); err != nil {
+			onError(fmt.Errorf("%s: %w", name, err))
+			return
+		}
+		if options.Checkpoint != nil {
+			if err := options.Checkpoint.Ack(batch.ID); err != nil {
+				onError(fmt.Errorf("%s: %w", name, err))
+				return
+			}
+		}
+	}
+}
+
+// indexBatch posts docs to _bulk, retrying only the documents that
+// failed for retryable reasons (429, 5xx, connection errors), with
+// exponential backoff between attempts; a whole response that failed
+// with another status or could not be parsed is retried wholesale (see
+// classify). Permanent per-item failures are written to options.Errors,
+// if configured, instead of aborting the batch.
+func indexBatch(name string, options Options, docs []string) error {
+	maxRetries := options.MaxRetries
+	if maxRetries <= 0 {
+		maxRetries = defaultMaxRetries
+	}
+	backoffInitial := options.BackoffInitial
+	if backoffInitial <= 0 {
+		backoffInitial = defaultBackoffInitial
+	}
+	backoffMax := options.BackoffMax
+	if backoffMax <= 0 {
+		backoffMax = defaultBackoffMax
+	}
+
+	pending := docs
+	backoff := backoffInitial
+
+	for attempt := 0; len(pending) > 0; attempt++ {
+		if options.Coordinator != nil {
+			options.Coordinator.Wait()
+		}
+
+		resp, reqErr := postBulk(options, pending)
+		retryable, permanent := classify(pending, resp, reqErr)
+
+		for _, f := range permanent {
+			if options.Errors == nil {
+				return fmt.Errorf("%s: document permanently rejected with status %d: %s (pass -errors-file to continue past these)", name, f.Status, f.Error)
+			}
+			if err := options.Errors.Write(f); err != nil {
+				return err
+			}
+		}
+
+		if len(retryable) == 0 {
+			if options.Coordinator != nil {
+				options.Coordinator.ReportSuccess()
+			}
+			return nil
+		}
+		if options.Coordinator != nil {
+			options.Coordinator.ReportFailure()
+		}
+		if attempt >= maxRetries {
+			return fmt.Errorf("%s: giving up after %d retries, %d documents dropped: %v", name, attempt, len(retryable), reqErr)
+		}
+
+		sleep := jitter(backoff)
+		if sleep > backoffMax {
+			sleep = backoffMax
+		}
+		if options.Verbose {
+			log.Printf("%s: retrying %d documents in %s (attempt %d)\n", name, len(retryable), sleep, attempt+1)
+		}
+		time.Sleep(sleep)
+		backoff *= 2
+		if backoff > backoffMax {
+			backoff = backoffMax
+		}
+		pending = retryable
+	}
+	return nil
+}
+
+// isRetryableStatus reports whether a bulk item, or the whole response,
+// is worth retrying: queue pressure (429) and transient server errors
+// (5xx), as opposed to a permanent, client-side document error.
+func isRetryableStatus(status int) bool {
+	return status == 429 || status >= 500
+}
+
+type bulkItemResult struct {
+	Status int `json:"status"`
+	Error  *struct {
+		Type   string `json:"type"`
+		Reason string `json:"reason"`
+	} `json:"error"`
+}
+
+type bulkResponseBody struct {
+	Errors bool `json:"errors"`
+	Items  []struct {
+		Index bulkItemResult `json:"index"`
+	} `json:"items"`
+}
+
+// classify splits docs into the ones worth retrying and the ones
+// Elasticsearch permanently rejected, based on the _bulk response. A
+// connection error, or a retryable status on the response as a whole,
+// means every doc is retried; an unparsable or hard-failed response is
+// retried wholesale too, since we can't tell which documents actually
+// landed.
+func classify(docs []string, resp *http.Response, reqErr error) (retryable []string, permanent []failedDoc) {
+	if reqErr != nil {
+		return docs, nil
+	}
+	defer resp.Body.Close()
+
+	if isRetryableStatus(resp.StatusCode) {
+		return docs, nil
+	}
+	if resp.StatusCode >= 400 {
+		return docs, nil
+	}
+
+	var body bulkResponseBody
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return docs, nil
+	}
+	if !body.Errors {
+		return nil, nil
+	}
+	for i, item := range body.Items {
+		if item.Index.Error == nil || i >= len(docs) {
+			continue
+		}
+		if isRetryableStatus(item.Index.Status) {
+			retryable = append(retryable, docs[i])
+			continue
+		}
+		permanent = append(permanent, failedDoc{
+			Doc:    json.RawMessage(docs[i]),
+			Status: item.Index.Status,
+			Error:  item.Index.Error.Reason,
+		})
+	}
+	return retryable, permanent
+}
+
+// jitter returns a randomized duration in [d/2, 3d/2), to keep retrying
+// workers from synchronizing on the same schedule.
+func jitter(d time.Duration) time.Duration {
+	if d <= 0 {
+		return d
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d)))
+}
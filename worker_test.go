@@ -0,0 +1,100 @@
+package esbulk
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func resp(status int, body string) *http.Response {
+	return &http.Response{
+		StatusCode: status,
+		Body:       io.NopCloser(bytes.NewBufferString(body)),
+	}
+}
+
+func TestClassify(t *testing.T) {
+	docs := []string{`{"a":1}`, `{"b":2}`}
+
+	cases := []struct {
+		name          string
+		resp          *http.Response
+		reqErr        error
+		wantRetryable []string
+		wantPermanent int
+	}{
+		{
+			name:          "connection error retries everything",
+			resp:          nil,
+			reqErr:        errors.New("connection refused"),
+			wantRetryable: docs,
+		},
+		{
+			name:          "whole-response 429 retries everything",
+			resp:          resp(429, `{}`),
+			wantRetryable: docs,
+		},
+		{
+			name:          "whole-response 5xx retries everything",
+			resp:          resp(503, `{}`),
+			wantRetryable: docs,
+		},
+		{
+			name:          "whole-response 4xx retries everything wholesale",
+			resp:          resp(400, `bad request`),
+			wantRetryable: docs,
+		},
+		{
+			name:          "malformed JSON body retries everything wholesale",
+			resp:          resp(200, `not json`),
+			wantRetryable: docs,
+		},
+		{
+			name: "no per-item errors",
+			resp: resp(200, `{"errors":false,"items":[{"index":{"status":201}},{"index":{"status":201}}]}`),
+		},
+		{
+			name:          "retryable item status splits out only that item",
+			resp:          resp(200, `{"errors":true,"items":[{"index":{"status":201}},{"index":{"status":429,"error":{"type":"es_rejected_execution_exception","reason":"queue full"}}}]}`),
+			wantRetryable: []string{docs[1]},
+		},
+		{
+			name:          "permanent item status is not retried",
+			resp:          resp(200, `{"errors":true,"items":[{"index":{"status":201}},{"index":{"status":400,"error":{"type":"mapper_parsing_exception","reason":"failed to parse"}}}]}`),
+			wantPermanent: 1,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			retryable, permanent := classify(docs, c.resp, c.reqErr)
+			if len(retryable) != len(c.wantRetryable) {
+				t.Fatalf("retryable = %v, want %v", retryable, c.wantRetryable)
+			}
+			for i := range retryable {
+				if retryable[i] != c.wantRetryable[i] {
+					t.Fatalf("retryable[%d] = %q, want %q", i, retryable[i], c.wantRetryable[i])
+				}
+			}
+			if len(permanent) != c.wantPermanent {
+				t.Fatalf("permanent = %d, want %d", len(permanent), c.wantPermanent)
+			}
+		})
+	}
+}
+
+func TestJitter(t *testing.T) {
+	if got := jitter(0); got != 0 {
+		t.Fatalf("jitter(0) = %v, want 0", got)
+	}
+	d := 100 * time.Millisecond
+	for i := 0; i < 100; i++ {
+		got := jitter(d)
+		if got < d/2 || got >= 3*d/2 {
+			t.Fatalf("jitter(%v) = %v, want in [%v, %v)", d, got, d/2, 3*d/2)
+		}
+	}
+}